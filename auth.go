@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/icholy/digest"
+)
+
+// Authenticator wraps a base http.RoundTripper with the credentials needed
+// to talk to a printer. PrusaCollector and ProxyHandler for a given printer
+// always share the same Authenticator, so they authenticate identically.
+type Authenticator interface {
+	Transport(base http.RoundTripper) http.RoundTripper
+}
+
+// digestAuthenticator is the original (and still default) auth mode, used
+// by PrusaLink's built-in HTTP digest auth.
+type digestAuthenticator struct {
+	Username string
+	Password string
+}
+
+func (a digestAuthenticator) Transport(base http.RoundTripper) http.RoundTripper {
+	return &digest.Transport{
+		Username:  a.Username,
+		Password:  a.Password,
+		Transport: base,
+	}
+}
+
+// apiKeyAuthenticator sends the X-Api-Key header used by newer PrusaLink and
+// Prusa Connect firmware.
+type apiKeyAuthenticator struct {
+	Key string
+}
+
+func (a apiKeyAuthenticator) Transport(base http.RoundTripper) http.RoundTripper {
+	return &headerTransport{base: base, header: "X-Api-Key", value: a.Key}
+}
+
+// bearerAuthenticator sends an Authorization: Bearer header, used by the
+// Prusa Connect cloud API.
+type bearerAuthenticator struct {
+	Token string
+}
+
+func (a bearerAuthenticator) Transport(base http.RoundTripper) http.RoundTripper {
+	return &headerTransport{base: base, header: "Authorization", value: "Bearer " + a.Token}
+}
+
+// headerTransport adds a single static header to every outgoing request.
+type headerTransport struct {
+	base   http.RoundTripper
+	header string
+	value  string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(t.header, t.value)
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// newAuthenticator builds an Authenticator for mode ("", "digest", "apikey",
+// or "bearer"; "" is an alias for "digest"), validating that the apikey and
+// bearer modes were given a key or token. Digest credentials are not checked.
+func newAuthenticator(mode, username, password, apiKey, token string) (Authenticator, error) {
+	switch mode {
+	case "", "digest":
+		return digestAuthenticator{Username: username, Password: password}, nil
+	case "apikey":
+		if apiKey == "" {
+			return nil, fmt.Errorf("-api-key is required for -auth-mode=apikey")
+		}
+		return apiKeyAuthenticator{Key: apiKey}, nil
+	case "bearer":
+		if token == "" {
+			return nil, fmt.Errorf("-token is required for -auth-mode=bearer")
+		}
+		return bearerAuthenticator{Token: token}, nil
+	default:
+		return nil, fmt.Errorf("invalid auth mode %q: must be digest, apikey, or bearer", mode)
+	}
+}
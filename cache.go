@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cachedResponse is an immutable snapshot of an upstream response, since the
+// original's body can only be read once but may need to be replayed to
+// several waiting callers.
+type cachedResponse struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+	expires    time.Time
+}
+
+func snapshotResponse(res *http.Response, ttl time.Duration) (*cachedResponse, error) {
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedResponse{
+		statusCode: res.StatusCode,
+		header:     res.Header.Clone(),
+		body:       body,
+		expires:    time.Now().Add(ttl),
+	}, nil
+}
+
+func (c *cachedResponse) httpResponse() *http.Response {
+	return &http.Response{
+		StatusCode: c.statusCode,
+		Header:     c.header,
+		Body:       io.NopCloser(bytes.NewReader(c.body)),
+	}
+}
+
+// cacheEntry is a single in-flight-or-resolved fetch, shared by every caller
+// asking for the same URL concurrently.
+type cacheEntry struct {
+	ready    chan struct{} // closed once response/err is populated
+	response *cachedResponse
+	err      error
+}
+
+// upstreamCache caches GET responses for a short TTL and coalesces
+// concurrent fetches of the same URL into a single upstream request. Prusa
+// printers run a single-threaded HTTP server that falls over when several
+// Prometheus scrapers, Grafana panels and the web UI all poll it at once.
+type upstreamCache struct {
+	client *http.Client
+	ttl    time.Duration
+	hits   prometheus.Counter
+	misses prometheus.Counter
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+func newUpstreamCache(client *http.Client, ttl time.Duration, reg prometheus.Registerer) *upstreamCache {
+	c := &upstreamCache{
+		client:  client,
+		ttl:     ttl,
+		entries: make(map[string]*cacheEntry),
+		hits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "pruxy_upstream_cache_hits_total",
+			Help: "Total number of upstream GET requests served without issuing a new upstream request, whether from a fresh cache entry or by coalescing onto one already in flight.",
+		}),
+		misses: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "pruxy_upstream_cache_misses_total",
+			Help: "Total number of upstream GET requests that issued a new request to the printer.",
+		}),
+	}
+
+	if reg != nil {
+		reg.MustRegister(c.hits, c.misses)
+	}
+
+	return c
+}
+
+// Get returns a response for uri, fresh from cache if the previous fetch is
+// still within the TTL. Concurrent callers for the same uri share a single
+// upstream request rather than each issuing their own.
+func (c *upstreamCache) Get(uri string) (*http.Response, error) {
+	c.mu.Lock()
+	if entry, ok := c.entries[uri]; ok {
+		select {
+		case <-entry.ready:
+			if entry.err == nil && time.Now().Before(entry.response.expires) {
+				c.mu.Unlock()
+				c.hits.Inc()
+				return entry.response.httpResponse(), nil
+			}
+			// Expired; fall through and start a fresh fetch below.
+		default:
+			// A fetch for this uri is already in flight; wait for it and
+			// share its result instead of issuing a second request. That
+			// makes this a hit too: it saves an upstream round trip just
+			// like a fresh cache entry would.
+			c.mu.Unlock()
+			<-entry.ready
+			c.hits.Inc()
+			if entry.err != nil {
+				return nil, entry.err
+			}
+			return entry.response.httpResponse(), nil
+		}
+	}
+
+	entry := &cacheEntry{ready: make(chan struct{})}
+	c.entries[uri] = entry
+	c.mu.Unlock()
+
+	c.misses.Inc()
+	res, err := c.client.Get(uri)
+	if err == nil {
+		entry.response, err = snapshotResponse(res, c.ttl)
+	}
+	entry.err = err
+	close(entry.ready)
+
+	if err != nil {
+		c.mu.Lock()
+		delete(c.entries, uri)
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	return entry.response.httpResponse(), nil
+}
+
+// printerCaches lazily creates and shares one upstreamCache and one untimed
+// proxy http.Client per printer name, so collector scrapes, cached GETs and
+// proxied requests for the same printer all reuse the same connections.
+type printerCaches struct {
+	mu      sync.Mutex
+	caches  map[string]*upstreamCache
+	proxies map[string]*http.Client
+}
+
+func (p *printerCaches) get(cfg PrinterConfig) *upstreamCache {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.caches == nil {
+		p.caches = make(map[string]*upstreamCache)
+	}
+	if c, ok := p.caches[cfg.Name]; ok {
+		return c
+	}
+
+	// Already validated by LoadConfig.
+	auth, err := cfg.Authenticator()
+	if err != nil {
+		panic(err)
+	}
+
+	c := newUpstreamCache(printerClient(auth, *timeout), *cacheTTL, nil)
+	p.caches[cfg.Name] = c
+	return c
+}
+
+// proxyClient lazily creates and shares one http.Client per printer name for
+// printersHandler to forward requests that don't go through upstreamCache
+// (non-GETs, uploads, downloads, SSE). Unlike the cache's client, it carries
+// no timeout, matching the single-printer proxy path.
+func (p *printerCaches) proxyClient(cfg PrinterConfig) *http.Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.proxies == nil {
+		p.proxies = make(map[string]*http.Client)
+	}
+	if c, ok := p.proxies[cfg.Name]; ok {
+		return c
+	}
+
+	// Already validated by LoadConfig.
+	auth, err := cfg.Authenticator()
+	if err != nil {
+		panic(err)
+	}
+
+	c := printerClient(auth, 0)
+	p.proxies[cfg.Name] = c
+	return c
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes a fleet of printers to scrape or proxy, loaded from the
+// file passed via -config.
+type Config struct {
+	Printers []PrinterConfig `json:"printers" yaml:"printers"`
+}
+
+// PrinterConfig is the per-printer configuration loaded from the config
+// file. Name identifies the printer in the /probe?target= and
+// /printers/{name}/... routes.
+type PrinterConfig struct {
+	Name    string            `json:"name" yaml:"name"`
+	Address string            `json:"address" yaml:"address"`
+	Labels  map[string]string `json:"labels" yaml:"labels"`
+
+	// AuthMode is "", "digest", "apikey", or "bearer"; "" is an alias for
+	// "digest". Username/Password are used for digest, APIKey for apikey,
+	// Token for bearer.
+	AuthMode string `json:"auth_mode" yaml:"auth_mode"`
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	APIKey   string `json:"api_key" yaml:"api_key"`
+	Token    string `json:"token" yaml:"token"`
+}
+
+// Authenticator builds the Authenticator for this printer.
+func (p PrinterConfig) Authenticator() (Authenticator, error) {
+	return newAuthenticator(p.AuthMode, p.Username, p.Password, p.APIKey, p.Token)
+}
+
+// LoadConfig reads a printer fleet configuration from path. The format
+// (YAML or JSON) is inferred from the file extension; anything other than
+// ".json" is parsed as YAML.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if strings.HasSuffix(path, ".json") {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parsing %s as JSON: %w", path, err)
+		}
+	} else {
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parsing %s as YAML: %w", path, err)
+		}
+	}
+
+	if len(cfg.Printers) == 0 {
+		return nil, fmt.Errorf("%s: no printers configured", path)
+	}
+
+	seen := make(map[string]bool, len(cfg.Printers))
+	for _, p := range cfg.Printers {
+		if p.Name == "" {
+			return nil, fmt.Errorf("%s: printer with address %q is missing a name", path, p.Address)
+		}
+		if p.Address == "" {
+			return nil, fmt.Errorf("%s: printer %q is missing an address", path, p.Name)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("%s: duplicate printer name %q", path, p.Name)
+		}
+		seen[p.Name] = true
+
+		if _, err := p.Authenticator(); err != nil {
+			return nil, fmt.Errorf("%s: printer %q: %w", path, p.Name, err)
+		}
+	}
+
+	return &cfg, nil
+}
+
+// Find returns the printer with the given name, if any.
+func (c *Config) Find(name string) (PrinterConfig, bool) {
+	for _, p := range c.Printers {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return PrinterConfig{}, false
+}
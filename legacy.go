@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// legacyPrinterResponse is the response shape of the pre-v1 PrusaLink
+// (and Prusa Connect 4.4.0-era) /api/printer endpoint.
+type legacyPrinterResponse struct {
+	Telemetry   legacyTelemetry   `json:"telemetry"`
+	Temperature legacyTemperature `json:"temperature"`
+	State       legacyState       `json:"state"`
+}
+
+type legacyTelemetry struct {
+	PrintSpeed *int     `json:"print-speed"`
+	Flow       *int     `json:"flow"`
+	AxisX      *float64 `json:"axis-x"`
+	AxisY      *float64 `json:"axis-y"`
+	AxisZ      *float64 `json:"axis-z"`
+	FanHotend  *int     `json:"fan-hotend"`
+	FanPrint   *int     `json:"fan-print"`
+}
+
+type legacyTemperature struct {
+	Tool0 legacyTempReading `json:"tool0"`
+	Bed   legacyTempReading `json:"bed"`
+}
+
+type legacyTempReading struct {
+	Actual *float64 `json:"actual"`
+	Target *float64 `json:"target"`
+}
+
+type legacyState struct {
+	Text string `json:"text"`
+}
+
+// collectLegacyPrinter emits the same prusa_* metrics as collectStatus, but
+// parsed from the legacy /api/printer schema.
+func (c *PrusaCollector) collectLegacyPrinter(ch chan<- prometheus.Metric) error {
+	uri, err := url.JoinPath(c.address, "/api/printer")
+	if err != nil {
+		panic(err)
+	}
+
+	res, err := c.cache.Get(uri)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code: %d", res.StatusCode)
+	}
+
+	var printer legacyPrinterResponse
+	if err := json.NewDecoder(res.Body).Decode(&printer); err != nil {
+		return err
+	}
+
+	if printer.State.Text != "" {
+		ch <- prometheus.MustNewConstMetric(
+			c.desc("prusa_printer_state", "The current state of the printer", []string{"state"}),
+			prometheus.GaugeValue,
+			1,
+			strings.ToLower(printer.State.Text),
+		)
+	}
+
+	if actual := printer.Temperature.Tool0.Actual; actual != nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.desc("prusa_temperature_celsius", "The current temperature reading", []string{"sensor"}),
+			prometheus.GaugeValue,
+			*actual,
+			"nozzle",
+		)
+	}
+
+	if target := printer.Temperature.Tool0.Target; target != nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.desc("prusa_target_temperature_celsius", "The target temperature", []string{"sensor"}),
+			prometheus.GaugeValue,
+			*target,
+			"nozzle",
+		)
+	}
+
+	if actual := printer.Temperature.Bed.Actual; actual != nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.desc("prusa_temperature_celsius", "The current temperature reading", []string{"sensor"}),
+			prometheus.GaugeValue,
+			*actual,
+			"bed",
+		)
+	}
+
+	if target := printer.Temperature.Bed.Target; target != nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.desc("prusa_target_temperature_celsius", "The target temperature", []string{"sensor"}),
+			prometheus.GaugeValue,
+			*target,
+			"bed",
+		)
+	}
+
+	telemetry := printer.Telemetry
+
+	if telemetry.AxisX != nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.desc("prusa_axis_position", "The current axis position", []string{"axis"}),
+			prometheus.GaugeValue,
+			*telemetry.AxisX,
+			"x",
+		)
+	}
+
+	if telemetry.AxisY != nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.desc("prusa_axis_position", "The current axis position", []string{"axis"}),
+			prometheus.GaugeValue,
+			*telemetry.AxisY,
+			"y",
+		)
+	}
+
+	if telemetry.AxisZ != nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.desc("prusa_axis_position", "The current axis position", []string{"axis"}),
+			prometheus.GaugeValue,
+			*telemetry.AxisZ,
+			"z",
+		)
+	}
+
+	if telemetry.Flow != nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.desc("prusa_flow_percent", "The current flow percentage", nil),
+			prometheus.GaugeValue,
+			float64(*telemetry.Flow),
+		)
+	}
+
+	if telemetry.PrintSpeed != nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.desc("prusa_speed_percent", "The current speed percentage", nil),
+			prometheus.GaugeValue,
+			float64(*telemetry.PrintSpeed),
+		)
+	}
+
+	if telemetry.FanHotend != nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.desc("prusa_fan_speed_rpm", "The current fan RPM", []string{"fan"}),
+			prometheus.GaugeValue,
+			float64(*telemetry.FanHotend),
+			"hotend",
+		)
+	}
+
+	if telemetry.FanPrint != nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.desc("prusa_fan_speed_rpm", "The current fan RPM", []string{"fan"}),
+			prometheus.GaugeValue,
+			float64(*telemetry.FanPrint),
+			"print",
+		)
+	}
+
+	return nil
+}
+
+// legacyJobResponse is the response shape of the pre-v1 /api/job endpoint.
+type legacyJobResponse struct {
+	State    string `json:"state"`
+	Progress struct {
+		Completion    *float64 `json:"completion"`
+		PrintTime     *int     `json:"printTime"`
+		PrintTimeLeft *int     `json:"printTimeLeft"`
+	} `json:"progress"`
+}
+
+// collectLegacyJob emits the same prusa_job_* metrics as collectJobInfo, but
+// parsed from the legacy /api/job schema.
+func (c *PrusaCollector) collectLegacyJob(ch chan<- prometheus.Metric) error {
+	uri, err := url.JoinPath(c.address, "/api/job")
+	if err != nil {
+		panic(err)
+	}
+
+	res, err := c.cache.Get(uri)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code: %d", res.StatusCode)
+	}
+
+	var job legacyJobResponse
+	if err := json.NewDecoder(res.Body).Decode(&job); err != nil {
+		return err
+	}
+
+	if job.State == "" || job.State == "Offline" {
+		// No job is running.
+		return nil
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.desc("prusa_job_state", "The current state of the job", []string{"state"}),
+		prometheus.GaugeValue,
+		1,
+		strings.ToLower(job.State),
+	)
+
+	if job.Progress.Completion != nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.desc("prusa_job_progress_percent", "The current job progress", nil),
+			prometheus.GaugeValue,
+			*job.Progress.Completion,
+		)
+	}
+
+	if job.Progress.PrintTimeLeft != nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.desc("prusa_job_time_remaining_seconds", "The time remaining for the job", nil),
+			prometheus.GaugeValue,
+			float64(*job.Progress.PrintTimeLeft),
+		)
+	}
+
+	if job.Progress.PrintTime != nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.desc("prusa_job_time_printing_seconds", "The time the job has been printing", nil),
+			prometheus.GaugeValue,
+			float64(*job.Progress.PrintTime),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ProxyMetrics is the Prometheus instrumentation for ProxyHandler, analogous
+// to promhttp.InstrumentHandler but also tracking upstream failures
+// separately from HTTP status codes, since the printer's web server never
+// gets a chance to set one when h.client.Do itself fails. The "path" label
+// is actually a bounded route label (see routeLabel): ProxyHandler fronts
+// the printer's whole web UI, so the raw request path is unbounded.
+type ProxyMetrics struct {
+	requestsTotal   *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	upstreamErrors  *prometheus.CounterVec
+	inFlight        prometheus.Gauge
+}
+
+// NewProxyMetrics creates proxy instrumentation and registers it on reg.
+func NewProxyMetrics(reg prometheus.Registerer) *ProxyMetrics {
+	m := &ProxyMetrics{
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pruxy_proxy_requests_total",
+			Help: "Total number of proxied HTTP requests, by method, route and response code.",
+		}, []string{"method", "path", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "pruxy_proxy_request_duration_seconds",
+			Help: "Latency of proxied HTTP requests, by method and route.",
+		}, []string{"method", "path"}),
+		upstreamErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pruxy_proxy_upstream_errors_total",
+			Help: "Total number of proxied requests that failed before a response was received from the printer, by method and route.",
+		}, []string{"method", "path"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "pruxy_proxy_requests_in_flight",
+			Help: "Number of proxied HTTP requests currently being served.",
+		}),
+	}
+
+	reg.MustRegister(m.requestsTotal, m.requestDuration, m.upstreamErrors, m.inFlight)
+
+	return m
+}
+
+// statusRecorder wraps an http.ResponseWriter to capture the status code
+// written, since ProxyHandler otherwise has no way to learn it after the
+// fact for metrics.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// JobPoller periodically polls /api/v1/job and maintains cumulative job
+// history counters, which Collect cannot provide on its own since a scrape
+// only ever sees the instantaneous state and loses everything that happened
+// between scrapes.
+type JobPoller struct {
+	address  string
+	cache    *upstreamCache
+	interval time.Duration
+
+	jobsCompleted  *prometheus.CounterVec
+	filamentUsed   prometheus.Counter
+	printTimeTotal prometheus.Counter
+	lastFinished   prometheus.Gauge
+
+	lastState string // only ever touched from the Run goroutine
+}
+
+// NewJobPoller creates job history instrumentation for a single printer and
+// registers it on reg.
+func NewJobPoller(address string, cache *upstreamCache, interval time.Duration, constLabels prometheus.Labels, reg prometheus.Registerer) *JobPoller {
+	p := &JobPoller{
+		address:  address,
+		cache:    cache,
+		interval: interval,
+		jobsCompleted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "prusa_jobs_completed_total",
+			Help:        "Total number of print jobs that finished, by result.",
+			ConstLabels: constLabels,
+		}, []string{"result"}),
+		filamentUsed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "prusa_filament_used_meters_total",
+			Help:        "Total meters of filament used across completed print jobs.",
+			ConstLabels: constLabels,
+		}),
+		printTimeTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "prusa_print_time_seconds_total",
+			Help:        "Total time spent printing across completed print jobs, in seconds.",
+			ConstLabels: constLabels,
+		}),
+		lastFinished: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        "prusa_last_job_finished_timestamp_seconds",
+			Help:        "Unix timestamp of the last time a print job finished, regardless of result.",
+			ConstLabels: constLabels,
+		}),
+	}
+
+	reg.MustRegister(p.jobsCompleted, p.filamentUsed, p.printTimeTotal, p.lastFinished)
+
+	return p
+}
+
+// Run polls until ctx is done. It should be started in its own goroutine.
+func (p *JobPoller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.poll(); err != nil {
+			log.Printf("job poller for %s: %v", p.address, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll fetches the current job state once and, on a PRINTING -> terminal
+// state transition, increments the cumulative counters exactly once for
+// that job. PAUSED and other transient non-PRINTING states (BUSY, IDLE,
+// READY, ...) are not completions and are ignored, so a job that is paused
+// and resumed is still only counted once, when it actually finishes.
+func (p *JobPoller) poll() error {
+	uri, err := url.JoinPath(p.address, "/api/v1/job")
+	if err != nil {
+		panic(err)
+	}
+
+	res, err := p.cache.Get(uri)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var info JobInfo
+	switch res.StatusCode {
+	case http.StatusOK:
+		if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+			return err
+		}
+	case http.StatusNoContent:
+		info.State = "IDLE"
+	default:
+		return fmt.Errorf("status code: %d", res.StatusCode)
+	}
+
+	prevState := p.lastState
+	p.lastState = strings.ToUpper(info.State)
+
+	result, terminal := jobResult(p.lastState)
+	if prevState != "PRINTING" || !terminal {
+		return nil
+	}
+
+	p.jobsCompleted.WithLabelValues(result).Inc()
+	p.lastFinished.SetToCurrentTime()
+
+	if info.TimePrinting != nil {
+		p.printTimeTotal.Add(float64(*info.TimePrinting))
+	}
+	if info.File != nil && info.File.Meta != nil && info.File.Meta.EstimatedFilamentMeters != nil {
+		p.filamentUsed.Add(*info.File.Meta.EstimatedFilamentMeters)
+	}
+
+	return nil
+}
+
+// jobResult maps a terminal job state to its "result" label value. ok is
+// false for anything that isn't a terminal state (PRINTING, PAUSED, BUSY,
+// IDLE, ATTENTION, ...), which callers must treat as "job not actually
+// done" rather than recording it under some arbitrary label value.
+// ATTENTION (filament runout, a crash/M600, etc.) is deliberately excluded:
+// the printer typically returns to PRINTING and later reaches a real
+// terminal state, so counting it here would double-count the job.
+func jobResult(state string) (result string, ok bool) {
+	switch state {
+	case "FINISHED":
+		return "finished", true
+	case "STOPPED":
+		return "cancelled", true
+	case "ERROR":
+		return "failed", true
+	default:
+		return "", false
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -10,50 +11,107 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-
-	"github.com/icholy/digest"
 )
 
 var (
-	bind     = flag.String("bind", ":8080", "The address to bind to")
-	address  = flag.String("address", "", "The URI of the printer")
-	username = flag.String("username", "maker", "The username for the printer")
-	password = flag.String("password", os.Getenv("PRUSA_LINK_PASSWORD"), "The password for the printer")
-	timeout  = flag.Duration("timeout", 15*time.Second, "The timeout for metrics requests to the printer")
+	bind         = flag.String("bind", ":8080", "The address to bind to")
+	address      = flag.String("address", "", "The URI of the printer")
+	username     = flag.String("username", "maker", "The username for the printer")
+	password     = flag.String("password", os.Getenv("PRUSA_LINK_PASSWORD"), "The password for the printer")
+	timeout      = flag.Duration("timeout", 15*time.Second, "The timeout for metrics requests to the printer")
+	configPath   = flag.String("config", "", "Path to a YAML/JSON file listing multiple printers to scrape and proxy (see PrinterConfig)")
+	apiVersion   = flag.String("api-version", "auto", "Which PrusaLink API schema to use: auto, v1, or legacy")
+	cacheTTL     = flag.Duration("cache-ttl", 2*time.Second, "How long to cache and coalesce upstream requests to the printer")
+	pollInterval = flag.Duration("poll-interval", 0, "Interval for background polling of print-job history, producing prusa_jobs_completed_total and related counters (0 disables)")
+	authMode     = flag.String("auth-mode", "digest", "How to authenticate to the printer: digest, apikey, or bearer")
+	apiKey       = flag.String("api-key", os.Getenv("PRUSA_API_KEY"), "The X-Api-Key value, for -auth-mode=apikey")
+	token        = flag.String("token", os.Getenv("PRUSA_CONNECT_TOKEN"), "The bearer token, for -auth-mode=bearer")
 )
 
+// proxyRoutes lists the PrusaLink API paths worth a distinct metrics label.
+// ProxyHandler also fronts the printer's entire web UI (static assets,
+// uploaded files, arbitrary client-side routes), which would otherwise turn
+// the "path" label into an unbounded-cardinality one.
+var proxyRoutes = []string{
+	"/api/v1/status",
+	"/api/v1/job",
+	"/api/v1/info",
+	"/api/v1/files",
+	"/api/printer",
+	"/api/job",
+	"/api/files",
+}
+
+// routeLabel maps a request path to a bounded "path" label: a known API
+// endpoint keeps its path, everything else collapses to "other".
+func routeLabel(path string) string {
+	for _, route := range proxyRoutes {
+		if path == route || strings.HasPrefix(path, route+"/") {
+			return route
+		}
+	}
+	return "other"
+}
+
+// cacheableRoute reports whether path is one of the known JSON API
+// endpoints. Those are small and safe to buffer and cache; everything else
+// (the printer's web UI, static assets, gcode/file downloads) is proxied
+// straight through uncached, since upstreamCache would otherwise buffer an
+// arbitrarily large download into memory and keep it cached indefinitely.
+func cacheableRoute(path string) bool {
+	return routeLabel(path) != "other"
+}
+
 type ProxyHandler struct {
 	address string
 	client  *http.Client
+	// metrics is optional; when nil, ServeHTTP is not instrumented.
+	metrics *ProxyMetrics
+	// cache is optional; when set, GET requests for the known API routes
+	// (see cacheableRoute) are served through it instead of hitting the
+	// printer directly.
+	cache *upstreamCache
 }
 
 func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	uri, err := url.JoinPath(h.address, r.URL.Path)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	if h.metrics != nil {
+		h.metrics.inFlight.Inc()
+		defer h.metrics.inFlight.Dec()
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		w = rec
+
+		path := routeLabel(r.URL.Path)
+		defer func() {
+			duration := time.Since(start).Seconds()
+			h.metrics.requestDuration.WithLabelValues(r.Method, path).Observe(duration)
+			h.metrics.requestsTotal.WithLabelValues(r.Method, path, strconv.Itoa(rec.status)).Inc()
+		}()
 	}
 
-	req, err := http.NewRequest(r.Method, uri, bytes.NewReader(body))
+	uri, err := url.JoinPath(h.address, r.URL.Path)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Send the request
-	res, err := h.client.Do(req)
+	var res *http.Response
+	if h.cache != nil && r.Method == http.MethodGet && cacheableRoute(r.URL.Path) {
+		res, err = h.cache.Get(uri)
+		if err != nil && h.metrics != nil {
+			h.metrics.upstreamErrors.WithLabelValues(r.Method, routeLabel(r.URL.Path)).Inc()
+		}
+	} else {
+		res, err = h.forward(r, uri)
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -68,26 +126,121 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	io.Copy(w, res.Body)
 }
 
+// forward builds and sends a request for everything that can't be served
+// from cache: non-GET methods, requests outside the known API routes (the
+// web UI, static assets, file downloads), and every request when caching
+// is disabled. The response is streamed straight through by the caller
+// rather than buffered.
+func (h *ProxyHandler) forward(r *http.Request, uri string) (*http.Response, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(r.Method, uri, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := h.client.Do(req)
+	if err != nil {
+		if h.metrics != nil {
+			h.metrics.upstreamErrors.WithLabelValues(r.Method, routeLabel(r.URL.Path)).Inc()
+		}
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// apiVersionDetectTTL bounds how long an "auto" API-version detection is
+// trusted before resolveAPIVersion re-probes. Printer web UIs are
+// notoriously flaky, so a single transient 404 shouldn't permanently
+// downgrade a v1 printer to legacy parsing until the process restarts.
+const apiVersionDetectTTL = time.Minute
+
 type PrusaCollector struct {
 	address string
-	client  *http.Client
+	cache   *upstreamCache
+	// constLabels is attached to every prusa_* metric this collector
+	// produces, e.g. a "printer" label when scraping a fleet.
+	constLabels prometheus.Labels
+	// apiVersion is "auto", "v1", or "legacy" (see -api-version).
+	apiVersion string
+
+	versionMu       sync.Mutex
+	detectedVersion string    // cached result of probing for "auto"
+	detectedExpiry  time.Time // when detectedVersion should be re-probed
+}
+
+// desc builds a metric descriptor with the collector's constLabels applied,
+// so fleet metadata (printer name, custom labels) doesn't need to be
+// threaded through every collectXxx function individually.
+func (c *PrusaCollector) desc(name, help string, variableLabels []string) *prometheus.Desc {
+	return prometheus.NewDesc(name, help, variableLabels, c.constLabels)
 }
 
 func (c *PrusaCollector) Describe(ch chan<- *prometheus.Desc) {
 }
 
+// resolveAPIVersion returns "v1" or "legacy". When apiVersion is "auto" it
+// probes /api/v1/info and caches the result for apiVersionDetectTTL, since
+// pre-v1 PrusaLink and Prusa Connect 4.4.0-era firmware 404 on that path.
+func (c *PrusaCollector) resolveAPIVersion() (string, error) {
+	if c.apiVersion != "auto" {
+		return c.apiVersion, nil
+	}
+
+	c.versionMu.Lock()
+	defer c.versionMu.Unlock()
+
+	if c.detectedVersion != "" && time.Now().Before(c.detectedExpiry) {
+		return c.detectedVersion, nil
+	}
+
+	uri, err := url.JoinPath(c.address, "/api/v1/info")
+	if err != nil {
+		panic(err)
+	}
+
+	res, err := c.cache.Get(uri)
+	if err != nil {
+		return "", err
+	}
+	res.Body.Close()
+
+	c.detectedVersion = "v1"
+	if res.StatusCode == http.StatusNotFound {
+		c.detectedVersion = "legacy"
+	}
+	c.detectedExpiry = time.Now().Add(apiVersionDetectTTL)
+
+	return c.detectedVersion, nil
+}
+
 func (c *PrusaCollector) Collect(ch chan<- prometheus.Metric) {
+	version, err := c.resolveAPIVersion()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc("prusa_error", "An error occurred", nil), err)
+		return
+	}
+
+	fns := []func(chan<- prometheus.Metric) error{
+		func(ch chan<- prometheus.Metric) error { return c.collectStatus(ch, version) },
+		func(ch chan<- prometheus.Metric) error { return c.collectJobInfo(ch, version) },
+	}
+	if version == "v1" {
+		// The legacy schema has no equivalent of /api/v1/info.
+		fns = append(fns, c.collectInfo)
+	}
+
 	wg := sync.WaitGroup{}
-	for _, f := range []func(chan<- prometheus.Metric) error{
-		c.collectInfo,
-		c.collectStatus,
-		c.collectJobInfo,
-	} {
+	for _, f := range fns {
 		wg.Add(1)
 		go func(f func(chan<- prometheus.Metric) error) {
 			defer wg.Done()
 			if err := f(ch); err != nil {
-				ch <- prometheus.NewInvalidMetric(prometheus.NewDesc("prusa_error", "An error occurred", nil, nil), err)
+				ch <- prometheus.NewInvalidMetric(c.desc("prusa_error", "An error occurred", nil), err)
 			}
 		}(f)
 	}
@@ -107,7 +260,7 @@ func (c *PrusaCollector) collectInfo(ch chan<- prometheus.Metric) error {
 		panic(err)
 	}
 
-	res, err := c.client.Get(uri)
+	res, err := c.cache.Get(uri)
 	if err != nil {
 		return err
 	}
@@ -123,7 +276,7 @@ func (c *PrusaCollector) collectInfo(ch chan<- prometheus.Metric) error {
 	}
 
 	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc("prusa_printer_info", "The hostname of the printer", []string{"hostname", "serial"}, nil),
+		c.desc("prusa_printer_info", "The hostname of the printer", []string{"hostname", "serial"}),
 		prometheus.GaugeValue,
 		1,
 		info.Hostname,
@@ -132,7 +285,7 @@ func (c *PrusaCollector) collectInfo(ch chan<- prometheus.Metric) error {
 
 	if info.NozzleDiameter != nil {
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("prusa_nozzle_diameter_millimeters", "The diameter of the nozzle", nil, nil),
+			c.desc("prusa_nozzle_diameter_millimeters", "The diameter of the nozzle", nil),
 			prometheus.GaugeValue,
 			*info.NozzleDiameter,
 		)
@@ -140,7 +293,7 @@ func (c *PrusaCollector) collectInfo(ch chan<- prometheus.Metric) error {
 
 	if info.MinExtrusionTemp != nil {
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("prusa_min_extrusion_temperature_celsius", "The minimum extrusion temperature", nil, nil),
+			c.desc("prusa_min_extrusion_temperature_celsius", "The minimum extrusion temperature", nil),
 			prometheus.GaugeValue,
 			float64(*info.MinExtrusionTemp),
 		)
@@ -168,13 +321,17 @@ type PrinterStatus struct {
 	FanPrint     *int     `json:"fan_print"`
 }
 
-func (c *PrusaCollector) collectStatus(ch chan<- prometheus.Metric) error {
+func (c *PrusaCollector) collectStatus(ch chan<- prometheus.Metric, version string) error {
+	if version == "legacy" {
+		return c.collectLegacyPrinter(ch)
+	}
+
 	uri, err := url.JoinPath(c.address, "/api/v1/status")
 	if err != nil {
 		panic(err)
 	}
 
-	res, err := c.client.Get(uri)
+	res, err := c.cache.Get(uri)
 	if err != nil {
 		return err
 	}
@@ -192,7 +349,7 @@ func (c *PrusaCollector) collectStatus(ch chan<- prometheus.Metric) error {
 	printerStatus := status.Printer
 
 	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc("prusa_printer_state", "The current state of the printer", []string{"state"}, nil),
+		c.desc("prusa_printer_state", "The current state of the printer", []string{"state"}),
 		prometheus.GaugeValue,
 		1,
 		strings.ToLower(printerStatus.State),
@@ -200,7 +357,7 @@ func (c *PrusaCollector) collectStatus(ch chan<- prometheus.Metric) error {
 
 	if printerStatus.TempNozzle != nil {
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("prusa_temperature_celsius", "The current temperature reading", []string{"sensor"}, nil),
+			c.desc("prusa_temperature_celsius", "The current temperature reading", []string{"sensor"}),
 			prometheus.GaugeValue,
 			*printerStatus.TempNozzle,
 			"nozzle",
@@ -209,7 +366,7 @@ func (c *PrusaCollector) collectStatus(ch chan<- prometheus.Metric) error {
 
 	if printerStatus.TargetNozzle != nil {
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("prusa_target_temperature_celsius", "The target temperature", []string{"sensor"}, nil),
+			c.desc("prusa_target_temperature_celsius", "The target temperature", []string{"sensor"}),
 			prometheus.GaugeValue,
 			*printerStatus.TargetNozzle,
 			"nozzle",
@@ -218,7 +375,7 @@ func (c *PrusaCollector) collectStatus(ch chan<- prometheus.Metric) error {
 
 	if printerStatus.TempBed != nil {
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("prusa_temperature_celsius", "The current temperature reading", []string{"sensor"}, nil),
+			c.desc("prusa_temperature_celsius", "The current temperature reading", []string{"sensor"}),
 			prometheus.GaugeValue,
 			*printerStatus.TempBed,
 			"bed",
@@ -227,7 +384,7 @@ func (c *PrusaCollector) collectStatus(ch chan<- prometheus.Metric) error {
 
 	if printerStatus.TargetBed != nil {
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("prusa_target_temperature_celsius", "The target temperature", []string{"sensor"}, nil),
+			c.desc("prusa_target_temperature_celsius", "The target temperature", []string{"sensor"}),
 			prometheus.GaugeValue,
 			*printerStatus.TargetBed,
 			"bed",
@@ -236,7 +393,7 @@ func (c *PrusaCollector) collectStatus(ch chan<- prometheus.Metric) error {
 
 	if printerStatus.AxisX != nil {
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("prusa_axis_position", "The current axis position", []string{"axis"}, nil),
+			c.desc("prusa_axis_position", "The current axis position", []string{"axis"}),
 			prometheus.GaugeValue,
 			*printerStatus.AxisX,
 			"x",
@@ -245,7 +402,7 @@ func (c *PrusaCollector) collectStatus(ch chan<- prometheus.Metric) error {
 
 	if printerStatus.AxisY != nil {
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("prusa_axis_position", "The current axis position", []string{"axis"}, nil),
+			c.desc("prusa_axis_position", "The current axis position", []string{"axis"}),
 			prometheus.GaugeValue,
 			*printerStatus.AxisY,
 			"y",
@@ -254,7 +411,7 @@ func (c *PrusaCollector) collectStatus(ch chan<- prometheus.Metric) error {
 
 	if printerStatus.AxisZ != nil {
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("prusa_axis_position", "The current axis position", []string{"axis"}, nil),
+			c.desc("prusa_axis_position", "The current axis position", []string{"axis"}),
 			prometheus.GaugeValue,
 			*printerStatus.AxisZ,
 			"z",
@@ -263,7 +420,7 @@ func (c *PrusaCollector) collectStatus(ch chan<- prometheus.Metric) error {
 
 	if printerStatus.Flow != nil {
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("prusa_flow_percent", "The current flow percentage", nil, nil),
+			c.desc("prusa_flow_percent", "The current flow percentage", nil),
 			prometheus.GaugeValue,
 			float64(*printerStatus.Flow),
 		)
@@ -271,7 +428,7 @@ func (c *PrusaCollector) collectStatus(ch chan<- prometheus.Metric) error {
 
 	if printerStatus.Speed != nil {
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("prusa_speed_percent", "The current speed percentage", nil, nil),
+			c.desc("prusa_speed_percent", "The current speed percentage", nil),
 			prometheus.GaugeValue,
 			float64(*printerStatus.Speed),
 		)
@@ -279,7 +436,7 @@ func (c *PrusaCollector) collectStatus(ch chan<- prometheus.Metric) error {
 
 	if printerStatus.FanHotend != nil {
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("prusa_fan_speed_rpm", "The current fan RPM", []string{"fan"}, nil),
+			c.desc("prusa_fan_speed_rpm", "The current fan RPM", []string{"fan"}),
 			prometheus.GaugeValue,
 			float64(*printerStatus.FanHotend),
 			"hotend",
@@ -288,7 +445,7 @@ func (c *PrusaCollector) collectStatus(ch chan<- prometheus.Metric) error {
 
 	if printerStatus.FanPrint != nil {
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("prusa_fan_speed_rpm", "The current fan RPM", []string{"fan"}, nil),
+			c.desc("prusa_fan_speed_rpm", "The current fan RPM", []string{"fan"}),
 			prometheus.GaugeValue,
 			float64(*printerStatus.FanPrint),
 			"print",
@@ -303,15 +460,31 @@ type JobInfo struct {
 	Progress      *float64 `json:"progress"`
 	TimeRemaining *int     `json:"time_remaining"`
 	TimePrinting  *int     `json:"time_printing"`
+	File          *JobFile `json:"file"`
+}
+
+// JobFile is best-effort: not every firmware version populates filament
+// usage in the job's file metadata, so JobPoller treats a nil Meta or
+// EstimatedFilamentMeters as "unknown" rather than an error.
+type JobFile struct {
+	Meta *JobFileMeta `json:"meta"`
 }
 
-func (c *PrusaCollector) collectJobInfo(ch chan<- prometheus.Metric) error {
+type JobFileMeta struct {
+	EstimatedFilamentMeters *float64 `json:"estimated_filament_meters"`
+}
+
+func (c *PrusaCollector) collectJobInfo(ch chan<- prometheus.Metric, version string) error {
+	if version == "legacy" {
+		return c.collectLegacyJob(ch)
+	}
+
 	uri, err := url.JoinPath(c.address, "/api/v1/job")
 	if err != nil {
 		panic(err)
 	}
 
-	res, err := c.client.Get(uri)
+	res, err := c.cache.Get(uri)
 	if err != nil {
 		return err
 	}
@@ -332,7 +505,7 @@ func (c *PrusaCollector) collectJobInfo(ch chan<- prometheus.Metric) error {
 	}
 
 	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc("prusa_job_state", "The current state of the job", []string{"state"}, nil),
+		c.desc("prusa_job_state", "The current state of the job", []string{"state"}),
 		prometheus.GaugeValue,
 		1,
 		strings.ToLower(jobInfo.State),
@@ -340,7 +513,7 @@ func (c *PrusaCollector) collectJobInfo(ch chan<- prometheus.Metric) error {
 
 	if jobInfo.Progress != nil {
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("prusa_job_progress_percent", "The current job progress", nil, nil),
+			c.desc("prusa_job_progress_percent", "The current job progress", nil),
 			prometheus.GaugeValue,
 			*jobInfo.Progress,
 		)
@@ -348,7 +521,7 @@ func (c *PrusaCollector) collectJobInfo(ch chan<- prometheus.Metric) error {
 
 	if jobInfo.TimeRemaining != nil {
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("prusa_job_time_remaining_seconds", "The time remaining for the job", nil, nil),
+			c.desc("prusa_job_time_remaining_seconds", "The time remaining for the job", nil),
 			prometheus.GaugeValue,
 			float64(*jobInfo.TimeRemaining),
 		)
@@ -356,7 +529,7 @@ func (c *PrusaCollector) collectJobInfo(ch chan<- prometheus.Metric) error {
 
 	if jobInfo.TimePrinting != nil {
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("prusa_job_time_printing_seconds", "The time the job has been printing", nil, nil),
+			c.desc("prusa_job_time_printing_seconds", "The time the job has been printing", nil),
 			prometheus.GaugeValue,
 			float64(*jobInfo.TimePrinting),
 		)
@@ -365,24 +538,147 @@ func (c *PrusaCollector) collectJobInfo(ch chan<- prometheus.Metric) error {
 	return nil
 }
 
+// printerClient builds the HTTP client, authenticated by auth, used to talk
+// to a single printer.
+func printerClient(auth Authenticator, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: auth.Transport(nil),
+		Timeout:   timeout,
+	}
+}
+
+// probeHandler implements the multi-target exporter pattern (cf.
+// blackbox_exporter): each request scrapes exactly one printer, named by the
+// "target" query parameter, against a throwaway registry. Printers therefore
+// never share a label set, even if their custom labels differ.
+type probeHandler struct {
+	config *Config
+	caches *printerCaches
+}
+
+func (h *probeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	cfg, ok := h.config.Find(target)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown target %q", target), http.StatusNotFound)
+		return
+	}
+
+	constLabels := prometheus.Labels{"printer": cfg.Name}
+	for k, v := range cfg.Labels {
+		constLabels[k] = v
+	}
+
+	cache := h.caches.get(cfg)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&PrusaCollector{
+		address:     cfg.Address,
+		cache:       cache,
+		constLabels: constLabels,
+		apiVersion:  *apiVersion,
+	})
+	reg.MustRegister(cache.hits, cache.misses)
+
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{EnableOpenMetrics: true}).ServeHTTP(w, r)
+}
+
+// printersHandler routes /printers/{name}/... to a ProxyHandler for the
+// named printer in the fleet config.
+type printersHandler struct {
+	config  *Config
+	metrics *ProxyMetrics
+	caches  *printerCaches
+}
+
+func (h *printersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/printers/")
+	name, subPath, _ := strings.Cut(rest, "/")
+
+	cfg, ok := h.config.Find(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown printer %q", name), http.StatusNotFound)
+		return
+	}
+
+	proxy := &ProxyHandler{
+		address: cfg.Address,
+		client:  h.caches.proxyClient(cfg),
+		metrics: h.metrics,
+		cache:   h.caches.get(cfg),
+	}
+
+	r2 := r.Clone(r.Context())
+	r2.URL.Path = "/" + subPath
+	proxy.ServeHTTP(w, r2)
+}
+
 func main() {
 	flag.Parse()
 
+	switch *apiVersion {
+	case "auto", "v1", "legacy":
+	default:
+		log.Fatalf("invalid -api-version %q: must be auto, v1, or legacy", *apiVersion)
+	}
+
+	if *configPath != "" {
+		cfg, err := LoadConfig(*configPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// There is no single collector registry to share in fleet mode
+		// (each /probe scrape uses its own throwaway registry), so proxy
+		// instrumentation gets its own persistent one, served at /metrics.
+		reg := prometheus.NewRegistry()
+		metrics := NewProxyMetrics(reg)
+		caches := &printerCaches{}
+
+		if *pollInterval > 0 {
+			for _, p := range cfg.Printers {
+				constLabels := prometheus.Labels{"printer": p.Name}
+				for k, v := range p.Labels {
+					constLabels[k] = v
+				}
+				poller := NewJobPoller(p.Address, caches.get(p), *pollInterval, constLabels, reg)
+				go poller.Run(context.Background())
+			}
+		}
+
+		http.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+		http.Handle("/probe", &probeHandler{config: cfg, caches: caches})
+		http.Handle("/printers/", &printersHandler{config: cfg, metrics: metrics, caches: caches})
+		log.Fatal(http.ListenAndServe(*bind, nil))
+	}
+
 	if *address == "" {
 		log.Fatal("address is required")
 	}
 
+	auth, err := newAuthenticator(*authMode, *username, *password, *apiKey, *token)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	reg := prometheus.NewRegistry()
+	cache := newUpstreamCache(printerClient(auth, *timeout), *cacheTTL, reg)
 	reg.MustRegister(&PrusaCollector{
-		address: *address,
-		client: &http.Client{
-			Transport: &digest.Transport{
-				Username: *username,
-				Password: *password,
-			},
-			Timeout: *timeout,
-		},
+		address:    *address,
+		cache:      cache,
+		apiVersion: *apiVersion,
 	})
+	metrics := NewProxyMetrics(reg)
+
+	if *pollInterval > 0 {
+		poller := NewJobPoller(*address, cache, *pollInterval, nil, reg)
+		go poller.Run(context.Background())
+	}
 
 	http.Handle("/metrics", promhttp.HandlerFor(
 		reg,
@@ -391,13 +687,10 @@ func main() {
 		},
 	))
 	http.Handle("/", &ProxyHandler{
-		client: &http.Client{
-			Transport: &digest.Transport{
-				Username: *username,
-				Password: *password,
-			},
-		},
+		client:  printerClient(auth, 0),
 		address: *address,
+		metrics: metrics,
+		cache:   cache,
 	})
 	log.Fatal(http.ListenAndServe(*bind, nil))
 }